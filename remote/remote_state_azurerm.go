@@ -3,13 +3,19 @@ package remote
 import (
 	"context"
 	stderrors "errors"
+	"fmt"
+	"os"
 	"reflect"
 	"strconv"
+	"strings"
 
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/resources/armresources"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob"
 	"github.com/gruntwork-io/go-commons/errors"
 	"github.com/gruntwork-io/terragrunt/options"
 
@@ -30,6 +36,41 @@ type ExtendedRemoteStateConfigAzureRM struct {
 	SkipResourceGroupCreation  bool `mapstructure:"skip_resource_group_creation"`
 	SkipStorageAccountCreation bool `mapstructure:"skip_storage_account_creation"`
 	SkipContainerCreation      bool `mapstructure:"skip_container_creation"`
+	// SkipStateBlobCreation skips pre-creating an empty state blob. Set this if the state blob already exists
+	// or if the configured credentials aren't allowed to write to the container ahead of the first real write.
+	SkipStateBlobCreation bool `mapstructure:"skip_state_blob_creation"`
+	// Snapshot takes a point-in-time CreateSnapshot backup of the state blob during Initialize whenever one
+	// already exists, giving a per-init backup analogous to S3 bucket versioning.
+	Snapshot bool `mapstructure:"snapshot"`
+
+	// Storage account hardening, applied on creation and, whenever Initialize actually runs again, patched onto
+	// the existing account via Update. These fields are Terragrunt-only (see terragruntAzureRMOnlyConfigs) and
+	// never reach Terraform's stored backend config, so changing one of them alone does not make
+	// NeedsInitialization return true on its own; run `terragrunt init -reconfigure` to apply a hardening change
+	// to an account that's already been created.
+	AccountKind                     string            `mapstructure:"account_kind"`
+	AccountTier                     string            `mapstructure:"account_tier"`
+	AccountReplicationType          string            `mapstructure:"account_replication_type"`
+	MinTLSVersion                   string            `mapstructure:"min_tls_version"`
+	AllowBlobPublicAccess           bool              `mapstructure:"allow_blob_public_access"`
+	InfrastructureEncryptionEnabled bool              `mapstructure:"infrastructure_encryption_enabled"`
+	DefaultToOAuthAuthentication    bool              `mapstructure:"default_to_oauth_authentication"`
+	Tags                            map[string]string `mapstructure:"tags"`
+	// EnableHTTPSTrafficOnly and SharedAccessKeyEnabled default to true when unset, so a *bool tracks whether
+	// the user explicitly opted out.
+	EnableHTTPSTrafficOnly *bool `mapstructure:"enable_https_traffic_only"`
+	SharedAccessKeyEnabled *bool `mapstructure:"shared_access_key_enabled"`
+
+	BlobProperties *AzureRMBlobProperties `mapstructure:"blob_properties"`
+}
+
+// AzureRMBlobProperties configures the storage account's blob service: soft-delete retention, versioning, and
+// the change feed, applied via armstorage's BlobServicesClient after the account is created or updated.
+type AzureRMBlobProperties struct {
+	VersioningEnabled            bool  `mapstructure:"versioning_enabled"`
+	ChangeFeedEnabled            bool  `mapstructure:"change_feed_enabled"`
+	DeleteRetentionDays          int32 `mapstructure:"delete_retention_days"`
+	ContainerDeleteRetentionDays int32 `mapstructure:"container_delete_retention_days"`
 }
 
 var terragruntAzureRMOnlyConfigs = []string{
@@ -38,6 +79,20 @@ var terragruntAzureRMOnlyConfigs = []string{
 	"skip_resource_group_creation",
 	"skip_storage_account_creation",
 	"skip_container_creation",
+	"skip_state_blob_creation",
+	"snapshot",
+	"key_prefix",
+	"account_kind",
+	"account_tier",
+	"account_replication_type",
+	"min_tls_version",
+	"allow_blob_public_access",
+	"infrastructure_encryption_enabled",
+	"default_to_oauth_authentication",
+	"enable_https_traffic_only",
+	"shared_access_key_enabled",
+	"tags",
+	"blob_properties",
 }
 
 type RemoteStateConfigAzureRM struct {
@@ -47,6 +102,221 @@ type RemoteStateConfigAzureRM struct {
 	StorageAccountName string `mapstructure:"storage_account_name"`
 	ContainerName      string `mapstructure:"container_name"`
 	Key                string `mapstructure:"key"`
+
+	// KeyPrefix is prepended to Key when computing the effective backend key, mirroring the S3 initializer's
+	// workspace key prefix so Terragrunt-managed backends can multiplex workspaces/environments under one
+	// container without every caller hand-building the path.
+	KeyPrefix string `mapstructure:"key_prefix"`
+
+	// Environment selects the Azure cloud to talk to: "public" (default), "usgovernment", "china", "german",
+	// or a custom Azure Resource Manager metadata host URL. Falls back to the AZURE_ENVIRONMENT/ARM_ENVIRONMENT
+	// env vars when unset.
+	Environment string `mapstructure:"environment"`
+
+	// AccessKey and SASToken authenticate directly against blob storage, bypassing ARM and Azure AD entirely.
+	// AccessKey falls back to the ARM_ACCESS_KEY env var when unset.
+	AccessKey string `mapstructure:"access_key"`
+	SASToken  string `mapstructure:"sas_token"`
+
+	// UseMSI authenticates via Azure Managed Identity. MSIEndpoint declares the endpoint used to retrieve the
+	// identity token, for environments (e.g. Azure App Service) that don't use the standard IMDS endpoint. The
+	// Azure SDK only reads a non-default endpoint from the process-wide MSI_ENDPOINT environment variable, not
+	// from per-credential options, so only one msi_endpoint is supported per terragrunt process: set MSI_ENDPOINT
+	// in the process environment before running terragrunt rather than relying on this field to configure it,
+	// and set msi_endpoint here only to the same value, for documentation/validation.
+	UseMSI      bool   `mapstructure:"use_msi"`
+	MSIEndpoint string `mapstructure:"msi_endpoint"`
+
+	// ClientID/ClientSecret authenticate as a service principal via client secret. ClientID/ClientCertificatePath
+	// authenticate as a service principal via client certificate.
+	ClientID              string `mapstructure:"client_id"`
+	ClientSecret          string `mapstructure:"client_secret"`
+	ClientCertificatePath string `mapstructure:"client_certificate_path"`
+
+	// UseAzureADAuth forces blob data-plane calls (e.g. the state blob probe/creation) to use the resolved Azure
+	// AD credential instead of access_key, even when an access key is also configured.
+	UseAzureADAuth bool `mapstructure:"use_azuread_auth"`
+}
+
+// resolveAccessKey returns the configured storage account access key, falling back to the ARM_ACCESS_KEY env var
+// used by the legacy Terraform azurerm backend.
+func resolveAccessKey(config RemoteStateConfigAzureRM) string {
+	if config.AccessKey != "" {
+		return config.AccessKey
+	}
+	return os.Getenv("ARM_ACCESS_KEY")
+}
+
+// usesBlobLevelAuth reports whether config authenticates directly against blob storage (access key or SAS token)
+// rather than through an Azure AD credential, meaning there's no ARM credential available to manage resources.
+// UseAzureADAuth overrides an access key/SAS token that's also set, since it explicitly asks for the resolved
+// Azure AD credential to be used for blob data-plane calls instead.
+func usesBlobLevelAuth(config RemoteStateConfigAzureRM) bool {
+	if config.UseAzureADAuth {
+		return false
+	}
+	return resolveAccessKey(config) != "" || config.SASToken != ""
+}
+
+// credentialFactory picks the azcore.TokenCredential to use for ARM/Storage calls based on which auth fields
+// are set in config, mirroring the auth modes the legacy Terraform azurerm backend supported. It returns a nil
+// credential (and no error) when config uses blob-level auth (access key or SAS token), since that mode talks to
+// blob storage directly and never needs an Azure AD token.
+func credentialFactory(config RemoteStateConfigAzureRM, azureEnv *azureEnvironment) (azcore.TokenCredential, error) {
+	opts := azureEnv.clientOptions()
+
+	switch {
+	case usesBlobLevelAuth(config):
+		return nil, nil
+
+	case config.ClientSecret != "":
+		cred, err := azidentity.NewClientSecretCredential(config.TenantID, config.ClientID, config.ClientSecret, &azidentity.ClientSecretCredentialOptions{ClientOptions: opts})
+		if err != nil {
+			return nil, errors.WithStackTrace(err)
+		}
+		return cred, nil
+
+	case config.ClientCertificatePath != "":
+		certData, err := os.ReadFile(config.ClientCertificatePath)
+		if err != nil {
+			return nil, errors.WithStackTrace(err)
+		}
+		certs, key, err := azidentity.ParseCertificates(certData, nil)
+		if err != nil {
+			return nil, errors.WithStackTrace(err)
+		}
+		cred, err := azidentity.NewClientCertificateCredential(config.TenantID, config.ClientID, certs, key, &azidentity.ClientCertificateCredentialOptions{ClientOptions: opts})
+		if err != nil {
+			return nil, errors.WithStackTrace(err)
+		}
+		return cred, nil
+
+	case config.UseMSI:
+		// azidentity.NewManagedIdentityCredential only picks up a non-default endpoint (e.g. Azure App Service)
+		// from the process-wide MSI_ENDPOINT environment variable, not from ManagedIdentityCredentialOptions.
+		// Terragrunt runs many modules concurrently (run-all/--parallelism), and this function is called per
+		// module, so it must not os.Setenv here: whichever module's Setenv call landed last would silently win
+		// for every other module's concurrently-resolving MSI credential. Instead, treat "one MSI endpoint per
+		// terragrunt process" as a hard restriction and fail clearly when msi_endpoint doesn't match what's
+		// already in the process environment, rather than racing it.
+		if config.MSIEndpoint != "" && config.MSIEndpoint != os.Getenv("MSI_ENDPOINT") {
+			return nil, errors.WithStackTrace(fmt.Errorf(
+				"msi_endpoint %q does not match the MSI_ENDPOINT already set in this process's environment (%q): "+
+					"the Azure SDK only reads a non-default managed identity endpoint from the process-wide "+
+					"MSI_ENDPOINT environment variable, so only one msi_endpoint is supported per terragrunt "+
+					"process; set MSI_ENDPOINT before running terragrunt instead of relying on this field to "+
+					"configure it per module", config.MSIEndpoint, os.Getenv("MSI_ENDPOINT")))
+		}
+		msiOpts := &azidentity.ManagedIdentityCredentialOptions{ClientOptions: opts}
+		if config.ClientID != "" {
+			msiOpts.ID = azidentity.ClientID(config.ClientID)
+		}
+		cred, err := azidentity.NewManagedIdentityCredential(msiOpts)
+		if err != nil {
+			return nil, errors.WithStackTrace(err)
+		}
+		return cred, nil
+
+	default:
+		cred, err := azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{ClientOptions: opts})
+		if err != nil {
+			return nil, errors.WithStackTrace(err)
+		}
+		return cred, nil
+	}
+}
+
+// azureEnvironment bundles the SDK cloud configuration for a given Azure environment with the storage endpoint
+// suffix used to build blob service URLs, since azcore/cloud does not expose the latter.
+type azureEnvironment struct {
+	cloud.Configuration
+	StorageEndpointSuffix string
+}
+
+var (
+	azurePublicEnvironment = azureEnvironment{Configuration: cloud.AzurePublic, StorageEndpointSuffix: "core.windows.net"}
+	azureUSGovEnvironment  = azureEnvironment{Configuration: cloud.AzureGovernment, StorageEndpointSuffix: "core.usgovcloudapi.net"}
+	azureChinaEnvironment  = azureEnvironment{Configuration: cloud.AzureChina, StorageEndpointSuffix: "core.chinacloudapi.cn"}
+
+	// German cloud (Azure Deutschland) was retired by Microsoft and has no entry in azcore/cloud, but it's kept
+	// here for parity with the legacy Terraform azurerm backend, which still accepts it.
+	azureGermanEnvironment = azureEnvironment{
+		Configuration: cloud.Configuration{
+			ActiveDirectoryAuthorityHost: "https://login.microsoftonline.de/",
+			Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+				cloud.ResourceManager: {
+					Audience: "https://management.core.cloudapi.de/",
+					Endpoint: "https://management.microsoftazure.de/",
+				},
+			},
+		},
+		StorageEndpointSuffix: "core.cloudapi.de",
+	}
+)
+
+// resolveAzureEnvironment maps an `environment` config value (or the AZURE_ENVIRONMENT/ARM_ENVIRONMENT env vars,
+// used as a fallback when environment is unset) to the azcore cloud configuration and storage endpoint suffix to
+// use for every ARM/Storage client and credential in this file. A value that looks like a URL is treated as a
+// custom Azure Resource Manager metadata host (e.g. Azure Stack).
+func resolveAzureEnvironment(environment string) (*azureEnvironment, error) {
+	if environment == "" {
+		environment = os.Getenv("AZURE_ENVIRONMENT")
+	}
+	if environment == "" {
+		environment = os.Getenv("ARM_ENVIRONMENT")
+	}
+
+	switch strings.ToLower(strings.TrimSpace(environment)) {
+	case "", "public", "azurepublic", "azurecloud":
+		return &azurePublicEnvironment, nil
+	case "usgovernment", "azureusgovernment", "usgovcloud":
+		return &azureUSGovEnvironment, nil
+	case "china", "azurechina", "azurechinacloud":
+		return &azureChinaEnvironment, nil
+	case "german", "germancloud", "azuregermancloud":
+		return &azureGermanEnvironment, nil
+	default:
+		if isAzureMetadataHostURL(environment) {
+			return &azureEnvironment{
+				Configuration: cloud.Configuration{
+					ActiveDirectoryAuthorityHost: environment,
+					Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+						cloud.ResourceManager: {
+							Audience: environment,
+							Endpoint: environment,
+						},
+					},
+				},
+				StorageEndpointSuffix: azurePublicEnvironment.StorageEndpointSuffix,
+			}, nil
+		}
+
+		return nil, errors.WithStackTrace(InvalidAzureEnvironmentErr(environment))
+	}
+}
+
+// isAzureMetadataHostURL reports whether environment is a custom Azure Resource Manager metadata host URL
+// (e.g. for Azure Stack) rather than one of the named Azure clouds.
+func isAzureMetadataHostURL(environment string) bool {
+	return strings.HasPrefix(environment, "http://") || strings.HasPrefix(environment, "https://")
+}
+
+// InvalidAzureEnvironmentErr is returned when the `environment` config value (or its
+// AZURE_ENVIRONMENT/ARM_ENVIRONMENT env var fallback) does not match a known Azure cloud or a metadata host URL.
+type InvalidAzureEnvironmentErr string
+
+func (err InvalidAzureEnvironmentErr) Error() string {
+	return "invalid Azure environment \"" + string(err) + "\": expected public, usgovernment, china, german, or a metadata_host URL"
+}
+
+// clientOptions builds the azcore.ClientOptions carrying the resolved cloud configuration, shared by every ARM
+// and Storage client factory as well as every azidentity credential constructed in this file.
+func (env *azureEnvironment) clientOptions() azcore.ClientOptions {
+	return azcore.ClientOptions{Cloud: env.Configuration}
+}
+
+func (env *azureEnvironment) armClientOptions() *arm.ClientOptions {
+	return &arm.ClientOptions{ClientOptions: env.clientOptions()}
 }
 
 type AzureRMInitializer struct{}
@@ -62,43 +332,91 @@ func (azurermInitializer AzureRMInitializer) NeedsInitialization(remoteState *Re
 		return true, nil
 	}
 
-	config, err := parseAzureRMConfig(remoteState.Config)
+	azurermConfigExtended, err := parseExtendedAzureRMConfig(remoteState.Config)
 	if err != nil {
 		return false, err
 	}
+	config := azurermConfigExtended.remoteStateConfigAzureRM
+
+	// snapshot is a per-init action (a CreateSnapshot backup taken during Initialize), not a one-time resource to
+	// provision, so it must force Initialize to run on every real `terragrunt init` rather than only the first
+	// one the Azure resource existence checks below would otherwise make this idempotent past.
+	if azurermConfigExtended.Snapshot {
+		return true, nil
+	}
 
-	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	azureEnv, err := resolveAzureEnvironment(config.Environment)
 	if err != nil {
 		return false, err
 	}
+
 	ctx := context.Background()
 
-	resourcesClientFactory, err := armresources.NewClientFactory(config.SubscriptionID, cred, nil)
-	if err != nil {
-		return false, err
+	// access_key/sas_token auth talks to blob storage directly, so there's no ARM credential to probe the
+	// resource group or storage account with; the container is all that can be checked, via the data plane.
+	if usesBlobLevelAuth(config) {
+		if azurermConfigExtended.SkipContainerCreation {
+			return false, nil
+		}
+
+		blobClient, err := newBlobServiceClient(config, azureEnv, nil)
+		if err != nil {
+			return false, err
+		}
+
+		containerExists, err := blobContainerExistsDataPlane(ctx, blobClient, config.ContainerName)
+		if err != nil {
+			return false, err
+		}
+		return !containerExists, nil
 	}
 
-	resourceGroupClient := resourcesClientFactory.NewResourceGroupsClient()
-	resourceGroupExistenceResponse, err := resourceGroupClient.CheckExistence(ctx, config.ResourceGroupName, nil)
+	cred, err := credentialFactory(config, azureEnv)
 	if err != nil {
 		return false, err
 	}
-	if !resourceGroupExistenceResponse.Success {
-		return true, nil
-	}
 
-	// authorizer, err := auth.NewAuthorizerFromEnvironment()
-	// if err != nil {
-	// 	return false, err
-	// }
+	if !azurermConfigExtended.SkipResourceGroupCreation {
+		resourcesClientFactory, err := armresources.NewClientFactory(config.SubscriptionID, cred, azureEnv.armClientOptions())
+		if err != nil {
+			return false, err
+		}
 
-	// TODO: if resource group doesn't exist, return true
+		resourceGroupClient := resourcesClientFactory.NewResourceGroupsClient()
+		resourceGroupExistenceResponse, err := resourceGroupClient.CheckExistence(ctx, config.ResourceGroupName, nil)
+		if err != nil {
+			return false, err
+		}
+		if !resourceGroupExistenceResponse.Success {
+			return true, nil
+		}
+	}
 
-	// TODO: if storage account doesn't exist, return true
+	storageClientFactory, err := armstorage.NewClientFactory(config.SubscriptionID, cred, azureEnv.armClientOptions())
+	if err != nil {
+		return false, err
+	}
 
-	// TODO: if container doesn't exist, return true
+	if !azurermConfigExtended.SkipStorageAccountCreation {
+		accountExists, err := storageAccountExists(ctx, config.ResourceGroupName, config.StorageAccountName, storageClientFactory)
+		if err != nil {
+			return false, err
+		}
+		if !accountExists {
+			return true, nil
+		}
+	}
+
+	if !azurermConfigExtended.SkipContainerCreation {
+		containerExists, err := blobContainerExists(ctx, config.ResourceGroupName, config.StorageAccountName, config.ContainerName, storageClientFactory)
+		if err != nil {
+			return false, err
+		}
+		if !containerExists {
+			return true, nil
+		}
+	}
 
-	return true, nil // FIXME: return true for testing to trigger init every time
 	return false, nil
 }
 
@@ -153,47 +471,80 @@ func (azurermInitializer AzureRMInitializer) Initialize(remoteState *RemoteState
 
 	// ensure that only one goroutine can initialize the storage account
 	return stateAccessLock.StateBucketUpdate(azurermConfig.StorageAccountName, func() error {
-		cred, err := azidentity.NewDefaultAzureCredential(nil)
+		azureEnv, err := resolveAzureEnvironment(azurermConfig.Environment)
 		if err != nil {
 			return err
 		}
-		ctx := context.Background()
 
-		resourcesClientFactory, err := armresources.NewClientFactory(azurermConfig.SubscriptionID, cred, nil)
+		cred, err := credentialFactory(azurermConfig, azureEnv)
 		if err != nil {
 			return err
 		}
 
-		var resourceGroup *armresources.ResourceGroup
-		if !azurermConfigExtended.SkipResourceGroupCreation {
-			if resourceGroup, err = createResourceGroupIfNeeded(ctx, azurermConfig.ResourceGroupName, azurermConfigExtended.ResourceGroupLocation, resourcesClientFactory); err != nil {
+		// access_key/sas_token auth talks to blob storage directly and has no ARM credential to manage
+		// resources with, so the caller must have opted out of resource/account/container management.
+		if cred == nil && !(azurermConfigExtended.SkipResourceGroupCreation && azurermConfigExtended.SkipStorageAccountCreation && azurermConfigExtended.SkipContainerCreation) {
+			return errors.WithStackTrace(stderrors.New("access_key and sas_token authentication cannot create Azure resources; set skip_resource_group_creation, skip_storage_account_creation, and skip_container_creation when using them"))
+		}
+
+		ctx := context.Background()
+
+		if cred != nil {
+			resourcesClientFactory, err := armresources.NewClientFactory(azurermConfig.SubscriptionID, cred, azureEnv.armClientOptions())
+			if err != nil {
 				return err
 			}
-		} else {
-			if resourceGroup, err = getResourceGroupByName(ctx, azurermConfig.ResourceGroupName, resourcesClientFactory); err != nil {
-				return err
+
+			var resourceGroup *armresources.ResourceGroup
+			if !azurermConfigExtended.SkipResourceGroupCreation {
+				if resourceGroup, err = createResourceGroupIfNeeded(ctx, azurermConfig.ResourceGroupName, azurermConfigExtended.ResourceGroupLocation, resourcesClientFactory); err != nil {
+					return err
+				}
+			} else {
+				if resourceGroup, err = getResourceGroupByName(ctx, azurermConfig.ResourceGroupName, resourcesClientFactory); err != nil {
+					return err
+				}
 			}
-		}
 
-		storageClientFactory, err := armstorage.NewClientFactory(azurermConfig.SubscriptionID, cred, nil)
-		if err != nil {
-			return err
-		}
+			storageClientFactory, err := armstorage.NewClientFactory(azurermConfig.SubscriptionID, cred, azureEnv.armClientOptions())
+			if err != nil {
+				return err
+			}
 
-		if !azurermConfigExtended.SkipStorageAccountCreation {
-			location := azurermConfigExtended.StorageAccountLocation
-			if location == "" {
-				location = *resourceGroup.Location
+			if !azurermConfigExtended.SkipStorageAccountCreation {
+				location := azurermConfigExtended.StorageAccountLocation
+				if location == "" {
+					location = *resourceGroup.Location
+				}
+				if err := createOrUpdateStorageAccountIfNeeded(ctx, azurermConfig.ResourceGroupName, azurermConfig.StorageAccountName, location, azurermConfigExtended, storageClientFactory); err != nil {
+					return err
+				}
 			}
-			if err := createStorageAccountIfNeeded(ctx, azurermConfig.ResourceGroupName, azurermConfig.StorageAccountName, location, storageClientFactory); err != nil {
-				return err
+
+			if !azurermConfigExtended.SkipContainerCreation {
+				if err := createBlobContainerIfNeeded(ctx, azurermConfig.ResourceGroupName, azurermConfig.StorageAccountName, azurermConfig.ContainerName, storageClientFactory); err != nil {
+					return err
+				}
 			}
 		}
 
-		if !azurermConfigExtended.SkipContainerCreation {
-			if err := createBlobContainerIfNeeded(ctx, azurermConfig.ResourceGroupName, azurermConfig.StorageAccountName, azurermConfig.ContainerName, storageClientFactory); err != nil {
+		if azurermConfigExtended.Snapshot || !azurermConfigExtended.SkipStateBlobCreation {
+			blobClient, err := newBlobServiceClient(azurermConfig, azureEnv, cred)
+			if err != nil {
 				return err
 			}
+
+			if azurermConfigExtended.Snapshot {
+				if err := snapshotStateBlobIfExists(ctx, blobClient, azurermConfig.ContainerName, azurermConfig.effectiveKey()); err != nil {
+					return err
+				}
+			}
+
+			if !azurermConfigExtended.SkipStateBlobCreation {
+				if err := createStateBlobIfNeeded(ctx, blobClient, azurermConfig.ContainerName, azurermConfig.effectiveKey()); err != nil {
+					return err
+				}
+			}
 		}
 
 		return nil
@@ -222,9 +573,46 @@ func parseExtendedAzureRMConfig(config map[string]interface{}) (*ExtendedRemoteS
 	}
 
 	extendedConfig.remoteStateConfigAzureRM = azurermConfig
+
+	if err := validateAzureBlobPath(joinBlobKeyPrefix(azurermConfig.KeyPrefix, azurermConfig.Key)); err != nil {
+		return nil, err
+	}
+
 	return &extendedConfig, nil
 }
 
+// joinBlobKeyPrefix joins keyPrefix and key into the effective blob path, the same way the S3 initializer folds
+// its workspace key prefix into the final backend key.
+func joinBlobKeyPrefix(keyPrefix string, key string) string {
+	if keyPrefix == "" {
+		return key
+	}
+	return strings.Trim(keyPrefix, "/") + "/" + strings.TrimLeft(key, "/")
+}
+
+// effectiveKey returns the blob path Terragrunt actually reads/writes state at: KeyPrefix joined with Key.
+func (config RemoteStateConfigAzureRM) effectiveKey() string {
+	return joinBlobKeyPrefix(config.KeyPrefix, config.Key)
+}
+
+// validateAzureBlobPath rejects characters/segments that are forbidden (backslashes, doubled slashes) or
+// meaningless (".", "..") in an Azure blob name, since key_prefix and key are user-supplied and concatenated
+// before being used as a blob path.
+func validateAzureBlobPath(path string) error {
+	if strings.Contains(path, "\\") {
+		return errors.WithStackTrace(fmt.Errorf("blob path %q must not contain backslashes", path))
+	}
+	if strings.Contains(path, "//") {
+		return errors.WithStackTrace(fmt.Errorf("blob path %q must not contain consecutive slashes", path))
+	}
+	for _, segment := range strings.Split(path, "/") {
+		if segment == "." || segment == ".." {
+			return errors.WithStackTrace(fmt.Errorf("blob path %q must not contain \".\" or \"..\" segments", path))
+		}
+	}
+	return nil
+}
+
 func createResourceGroupIfNeeded(ctx context.Context, resourceGroupName string, resourceGroupLocation string, resourcesClientFactory *armresources.ClientFactory) (*armresources.ResourceGroup, error) {
 	resourceGroupClient := resourcesClientFactory.NewResourceGroupsClient()
 	resourceGroupResponse, err := resourceGroupClient.CreateOrUpdate(ctx, resourceGroupName, armresources.ResourceGroup{
@@ -247,56 +635,217 @@ func getResourceGroupByName(ctx context.Context, resourceGroupName string, resou
 	return &resourceGroupResponse.ResourceGroup, nil
 }
 
-func createStorageAccountIfNeeded(ctx context.Context, resourceGroupName string, storageAccountName string, location string, storageClientFactory *armstorage.ClientFactory) error {
+// boolOrDefault returns *ptr, or def if ptr is nil, for the hardening flags that default to true rather than
+// Go's zero value of false.
+func boolOrDefault(ptr *bool, def bool) bool {
+	if ptr == nil {
+		return def
+	}
+	return *ptr
+}
+
+func resolveAccountKind(azurermConfigExtended *ExtendedRemoteStateConfigAzureRM) armstorage.Kind {
+	if azurermConfigExtended.AccountKind == "" {
+		return armstorage.KindStorageV2
+	}
+	return armstorage.Kind(azurermConfigExtended.AccountKind)
+}
+
+func resolveAccountSKUName(azurermConfigExtended *ExtendedRemoteStateConfigAzureRM) armstorage.SKUName {
+	tier := azurermConfigExtended.AccountTier
+	if tier == "" {
+		tier = "Standard"
+	}
+	replicationType := azurermConfigExtended.AccountReplicationType
+	if replicationType == "" {
+		replicationType = "LRS"
+	}
+	return armstorage.SKUName(tier + "_" + replicationType)
+}
+
+func resolveMinTLSVersion(azurermConfigExtended *ExtendedRemoteStateConfigAzureRM) *armstorage.MinimumTLSVersion {
+	if azurermConfigExtended.MinTLSVersion == "" {
+		return to.Ptr(armstorage.MinimumTLSVersionTLS12)
+	}
+	return to.Ptr(armstorage.MinimumTLSVersion(azurermConfigExtended.MinTLSVersion))
+}
+
+func buildStorageAccountTags(tags map[string]string) map[string]*string {
+	if len(tags) == 0 {
+		return nil
+	}
+
+	armTags := make(map[string]*string, len(tags))
+	for key, value := range tags {
+		armTags[key] = to.Ptr(value)
+	}
+	return armTags
+}
+
+// createOrUpdateStorageAccountIfNeeded creates the storage account if it doesn't already exist in this resource
+// group/subscription, or, if it does, patches the hardening settings (TLS version, public access, tags, etc.)
+// onto it via Update instead of leaving them untouched. This only runs when Initialize itself runs, so it does
+// not by itself correct drift: these hardening fields are Terragrunt-only and are never compared against the
+// stored backend config, so NeedsInitialization won't notice a hardening-only change; re-apply one with
+// `terragrunt init -reconfigure`. Kind is immutable on Azure and is only honored on creation.
+func createOrUpdateStorageAccountIfNeeded(ctx context.Context, resourceGroupName string, storageAccountName string, location string, azurermConfigExtended *ExtendedRemoteStateConfigAzureRM, storageClientFactory *armstorage.ClientFactory) error {
 	storageAccountsClient := storageClientFactory.NewAccountsClient()
 
-	checkResponse, err := storageAccountsClient.CheckNameAvailability(ctx, armstorage.AccountCheckNameAvailabilityParameters{
-		Name: &storageAccountName,
-	}, nil)
+	tags := buildStorageAccountTags(azurermConfigExtended.Tags)
+	minTLSVersion := resolveMinTLSVersion(azurermConfigExtended)
+	enableHTTPSTrafficOnly := to.Ptr(boolOrDefault(azurermConfigExtended.EnableHTTPSTrafficOnly, true))
+	allowSharedKeyAccess := to.Ptr(boolOrDefault(azurermConfigExtended.SharedAccessKeyEnabled, true))
+	allowBlobPublicAccess := to.Ptr(azurermConfigExtended.AllowBlobPublicAccess)
+	defaultToOAuthAuthentication := to.Ptr(azurermConfigExtended.DefaultToOAuthAuthentication)
+
+	var encryption *armstorage.Encryption
+	if azurermConfigExtended.InfrastructureEncryptionEnabled {
+		encryption = &armstorage.Encryption{
+			RequireInfrastructureEncryption: to.Ptr(true),
+			KeySource:                       to.Ptr(armstorage.KeySourceMicrosoftStorage),
+			Services: &armstorage.EncryptionServices{
+				Blob: &armstorage.EncryptionService{Enabled: to.Ptr(true)},
+			},
+		}
+	}
+
+	// CheckNameAvailability reflects global Azure-wide name uniqueness, not whether the account exists in this
+	// resource group/subscription, so an unrelated subscription owning the name would wrongly send this down the
+	// Update path against a resource group/account pair Terragrunt never created. storageAccountExists asks the
+	// right question (the same one NeedsInitialization uses) via an RG-scoped GetProperties.
+	accountExists, err := storageAccountExists(ctx, resourceGroupName, storageAccountName, storageClientFactory)
 	if err != nil {
 		return err
 	}
 
-	// If name is available, then the storage account doesn't exist, and we create it here
-	if *checkResponse.NameAvailable {
+	if !accountExists {
 		pollerResponse, err := storageAccountsClient.BeginCreate(ctx, resourceGroupName, storageAccountName, armstorage.AccountCreateParameters{
-			Kind:     to.Ptr(armstorage.KindStorageV2),
+			Kind:     to.Ptr(resolveAccountKind(azurermConfigExtended)),
 			Location: &location,
-			SKU: &armstorage.SKU{
-				Name: to.Ptr(armstorage.SKUNameStandardLRS),
+			SKU:      &armstorage.SKU{Name: to.Ptr(resolveAccountSKUName(azurermConfigExtended))},
+			Tags:     tags,
+			Properties: &armstorage.AccountPropertiesCreateParameters{
+				MinimumTLSVersion:            minTLSVersion,
+				EnableHTTPSTrafficOnly:       enableHTTPSTrafficOnly,
+				AllowBlobPublicAccess:        allowBlobPublicAccess,
+				AllowSharedKeyAccess:         allowSharedKeyAccess,
+				DefaultToOAuthAuthentication: defaultToOAuthAuthentication,
+				Encryption:                   encryption,
 			},
 		}, nil)
 		if err != nil {
 			return err
 		}
 
-		_, err = pollerResponse.PollUntilDone(ctx, nil)
+		if _, err := pollerResponse.PollUntilDone(ctx, nil); err != nil {
+			return err
+		}
+	} else {
+		_, err := storageAccountsClient.Update(ctx, resourceGroupName, storageAccountName, armstorage.AccountUpdateParameters{
+			SKU:  &armstorage.SKU{Name: to.Ptr(resolveAccountSKUName(azurermConfigExtended))},
+			Tags: tags,
+			Properties: &armstorage.AccountPropertiesUpdateParameters{
+				MinimumTLSVersion:            minTLSVersion,
+				EnableHTTPSTrafficOnly:       enableHTTPSTrafficOnly,
+				AllowBlobPublicAccess:        allowBlobPublicAccess,
+				AllowSharedKeyAccess:         allowSharedKeyAccess,
+				DefaultToOAuthAuthentication: defaultToOAuthAuthentication,
+				Encryption:                   encryption,
+			},
+		}, nil)
 		if err != nil {
 			return err
 		}
 	}
 
+	if azurermConfigExtended.BlobProperties != nil {
+		if err := setBlobServiceProperties(ctx, resourceGroupName, storageAccountName, azurermConfigExtended.BlobProperties, storageClientFactory); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func createBlobContainerIfNeeded(ctx context.Context, resourceGroupName string, storageAccountName string, containerName string, storageClientFactory *armstorage.ClientFactory) error {
-	blobContainerClient := storageClientFactory.NewBlobContainersClient()
+func setBlobServiceProperties(ctx context.Context, resourceGroupName string, storageAccountName string, blobProperties *AzureRMBlobProperties, storageClientFactory *armstorage.ClientFactory) error {
+	blobServicesClient := storageClientFactory.NewBlobServicesClient()
 
-	var containerExists = true
-	_, err := blobContainerClient.Get(ctx, resourceGroupName, storageAccountName, containerName, nil)
+	_, err := blobServicesClient.SetServiceProperties(ctx, resourceGroupName, storageAccountName, armstorage.BlobServiceProperties{
+		BlobServiceProperties: &armstorage.BlobServicePropertiesProperties{
+			IsVersioningEnabled: to.Ptr(blobProperties.VersioningEnabled),
+			ChangeFeed: &armstorage.ChangeFeed{
+				Enabled: to.Ptr(blobProperties.ChangeFeedEnabled),
+			},
+			DeleteRetentionPolicy: &armstorage.DeleteRetentionPolicy{
+				Enabled: to.Ptr(blobProperties.DeleteRetentionDays > 0),
+				Days:    to.Ptr(blobProperties.DeleteRetentionDays),
+			},
+			ContainerDeleteRetentionPolicy: &armstorage.DeleteRetentionPolicy{
+				Enabled: to.Ptr(blobProperties.ContainerDeleteRetentionDays > 0),
+				Days:    to.Ptr(blobProperties.ContainerDeleteRetentionDays),
+			},
+		},
+	}, nil)
 	if err != nil {
-		var responseErr *azcore.ResponseError
-		if !stderrors.As(err, &responseErr) {
-			return err
-		}
+		return err
+	}
 
-		if responseErr.StatusCode == 404 {
-			containerExists = false
-		}
+	return nil
+}
+
+// storageAccountExists reports whether storageAccountName exists in resourceGroupName, treating a 404 from
+// GetProperties as "doesn't exist" and propagating every other error.
+func storageAccountExists(ctx context.Context, resourceGroupName string, storageAccountName string, storageClientFactory *armstorage.ClientFactory) (bool, error) {
+	_, err := storageClientFactory.NewAccountsClient().GetProperties(ctx, resourceGroupName, storageAccountName, nil)
+	if err == nil {
+		return true, nil
+	}
+
+	var responseErr *azcore.ResponseError
+	if stderrors.As(err, &responseErr) && responseErr.StatusCode == 404 {
+		return false, nil
+	}
+	return false, err
+}
+
+// blobContainerExists reports whether containerName exists via the ARM control-plane API, treating a 404 from
+// Get as "doesn't exist" and propagating every other error.
+func blobContainerExists(ctx context.Context, resourceGroupName string, storageAccountName string, containerName string, storageClientFactory *armstorage.ClientFactory) (bool, error) {
+	_, err := storageClientFactory.NewBlobContainersClient().Get(ctx, resourceGroupName, storageAccountName, containerName, nil)
+	if err == nil {
+		return true, nil
+	}
+
+	var responseErr *azcore.ResponseError
+	if stderrors.As(err, &responseErr) && responseErr.StatusCode == 404 {
+		return false, nil
+	}
+	return false, err
+}
+
+// blobContainerExistsDataPlane reports whether containerName exists via the blob data-plane API, for auth modes
+// (access key, SAS token) that have no ARM credential to call the control plane with.
+func blobContainerExistsDataPlane(ctx context.Context, blobClient *azblob.Client, containerName string) (bool, error) {
+	_, err := blobClient.NewContainerClient(containerName).GetProperties(ctx, nil)
+	if err == nil {
+		return true, nil
+	}
+
+	var responseErr *azcore.ResponseError
+	if stderrors.As(err, &responseErr) && responseErr.StatusCode == 404 {
+		return false, nil
+	}
+	return false, err
+}
+
+func createBlobContainerIfNeeded(ctx context.Context, resourceGroupName string, storageAccountName string, containerName string, storageClientFactory *armstorage.ClientFactory) error {
+	containerExists, err := blobContainerExists(ctx, resourceGroupName, storageAccountName, containerName, storageClientFactory)
+	if err != nil {
+		return err
 	}
 
 	if !containerExists {
-		_, err := blobContainerClient.Create(ctx, resourceGroupName, storageAccountName, containerName, armstorage.BlobContainer{
+		_, err := storageClientFactory.NewBlobContainersClient().Create(ctx, resourceGroupName, storageAccountName, containerName, armstorage.BlobContainer{
 			ContainerProperties: &armstorage.ContainerProperties{
 				PublicAccess: to.Ptr(armstorage.PublicAccessNone),
 			},
@@ -308,6 +857,79 @@ func createBlobContainerIfNeeded(ctx context.Context, resourceGroupName string,
 	return nil
 }
 
+// newBlobServiceClient builds an azblob client for the configured storage account's blob service endpoint,
+// picking the credential in this order: SAS token, then access key, then (if use_azuread_auth is set, or no other
+// credential is available) armCred, the Azure AD credential credentialFactory resolved for ARM calls. armCred may be nil when config uses an access key or SAS token exclusively.
+func newBlobServiceClient(config RemoteStateConfigAzureRM, azureEnv *azureEnvironment, armCred azcore.TokenCredential) (*azblob.Client, error) {
+	serviceURL := fmt.Sprintf("https://%s.blob.%s/", config.StorageAccountName, azureEnv.StorageEndpointSuffix)
+	opts := &azblob.ClientOptions{ClientOptions: azureEnv.clientOptions()}
+
+	switch {
+	case config.SASToken != "" && !config.UseAzureADAuth:
+		sasURL := serviceURL + "?" + strings.TrimPrefix(config.SASToken, "?")
+		return azblob.NewClientWithNoCredential(sasURL, opts)
+
+	case resolveAccessKey(config) != "" && !config.UseAzureADAuth:
+		sharedKeyCred, err := azblob.NewSharedKeyCredential(config.StorageAccountName, resolveAccessKey(config))
+		if err != nil {
+			return nil, errors.WithStackTrace(err)
+		}
+		return azblob.NewClientWithSharedKeyCredential(serviceURL, sharedKeyCred, opts)
+
+	case armCred != nil:
+		return azblob.NewClient(serviceURL, armCred, opts)
+
+	default:
+		return nil, errors.WithStackTrace(stderrors.New("no credential available to authenticate to blob storage: set access_key, sas_token, or an Azure AD credential"))
+	}
+}
+
+// createStateBlobIfNeeded uploads an empty state blob if one doesn't already exist at key. Blob-lease based
+// locking (what Terraform's azurerm backend uses for `terraform init`/`plan`) requires the state blob to exist
+// before a lease can be acquired, so without this the first init on a brand-new container fails with
+// BlobNotFound instead of just creating the state on first apply.
+func createStateBlobIfNeeded(ctx context.Context, blobClient *azblob.Client, containerName string, key string) error {
+	blockBlobClient := blobClient.NewContainerClient(containerName).NewBlockBlobClient(key)
+
+	_, err := blockBlobClient.GetProperties(ctx, nil)
+	if err == nil {
+		return nil
+	}
+
+	var responseErr *azcore.ResponseError
+	if !stderrors.As(err, &responseErr) || responseErr.StatusCode != 404 {
+		return err
+	}
+
+	_, err = blockBlobClient.UploadBuffer(ctx, []byte{}, nil)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// snapshotStateBlobIfExists takes a CreateSnapshot point-in-time backup of the state blob if one already exists,
+// giving users a per-init backup analogous to S3 bucket versioning. A missing blob (e.g. the very first init) is
+// a no-op rather than an error.
+func snapshotStateBlobIfExists(ctx context.Context, blobClient *azblob.Client, containerName string, key string) error {
+	blockBlobClient := blobClient.NewContainerClient(containerName).NewBlockBlobClient(key)
+
+	if _, err := blockBlobClient.GetProperties(ctx, nil); err != nil {
+		var responseErr *azcore.ResponseError
+		if stderrors.As(err, &responseErr) && responseErr.StatusCode == 404 {
+			return nil
+		}
+		return err
+	}
+
+	if _, err := blockBlobClient.CreateSnapshot(ctx, nil); err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (azurermInitializer AzureRMInitializer) GetTerraformInitArgs(config map[string]interface{}) map[string]interface{} {
 	var filteredConfig = make(map[string]interface{})
 
@@ -319,5 +941,18 @@ func (azurermInitializer AzureRMInitializer) GetTerraformInitArgs(config map[str
 		filteredConfig[key] = val
 	}
 
+	// key_prefix is a Terragrunt-only convenience; the azurerm backend only understands a single "key", so fold
+	// the prefix into it here rather than passing key_prefix through.
+	if azurermConfig, err := parseAzureRMConfig(config); err == nil && azurermConfig.KeyPrefix != "" {
+		filteredConfig["key"] = joinBlobKeyPrefix(azurermConfig.KeyPrefix, azurermConfig.Key)
+	}
+
+	// The azurerm backend's "environment" key only accepts the named Azure clouds (public, usgovernment, china,
+	// german); a custom metadata host URL belongs in its separate "metadata_host" key instead.
+	if environment, ok := filteredConfig["environment"].(string); ok && isAzureMetadataHostURL(environment) {
+		delete(filteredConfig, "environment")
+		filteredConfig["metadata_host"] = environment
+	}
+
 	return filteredConfig
 }
@@ -0,0 +1,226 @@
+package remote
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/storage/armstorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsesBlobLevelAuth(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name     string
+		config   RemoteStateConfigAzureRM
+		expected bool
+	}{
+		{"no auth configured", RemoteStateConfigAzureRM{}, false},
+		{"access key", RemoteStateConfigAzureRM{AccessKey: "key"}, true},
+		{"sas token", RemoteStateConfigAzureRM{SASToken: "token"}, true},
+		{"access key overridden by use_azuread_auth", RemoteStateConfigAzureRM{AccessKey: "key", UseAzureADAuth: true}, false},
+		{"sas token overridden by use_azuread_auth", RemoteStateConfigAzureRM{SASToken: "token", UseAzureADAuth: true}, false},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, testCase.expected, usesBlobLevelAuth(testCase.config))
+		})
+	}
+}
+
+// TestCredentialFactoryUseAzureADAuthOverridesBlobLevelAuth guards against credentialFactory short-circuiting to
+// a nil credential when use_azuread_auth is set alongside an access key or SAS token: in that case it must build
+// a real Azure AD credential instead, since use_azuread_auth explicitly asks for it.
+func TestCredentialFactoryUseAzureADAuthOverridesBlobLevelAuth(t *testing.T) {
+	t.Parallel()
+
+	config := RemoteStateConfigAzureRM{
+		AccessKey:      "key",
+		UseAzureADAuth: true,
+		TenantID:       "tenant",
+		ClientID:       "client",
+		ClientSecret:   "secret",
+	}
+
+	cred, err := credentialFactory(config, &azurePublicEnvironment)
+	require.NoError(t, err)
+	assert.NotNil(t, cred, "use_azuread_auth must yield a real credential even when access_key is also set")
+}
+
+func TestCredentialFactoryBlobLevelAuthReturnsNilCredential(t *testing.T) {
+	t.Parallel()
+
+	config := RemoteStateConfigAzureRM{AccessKey: "key"}
+
+	cred, err := credentialFactory(config, &azurePublicEnvironment)
+	require.NoError(t, err)
+	assert.Nil(t, cred)
+}
+
+func TestCredentialFactoryMSIEndpointMismatchIsRejected(t *testing.T) {
+	t.Setenv("MSI_ENDPOINT", "https://process-wide-endpoint.example.com/")
+
+	config := RemoteStateConfigAzureRM{
+		UseMSI:      true,
+		MSIEndpoint: "https://a-different-endpoint.example.com/",
+	}
+
+	_, err := credentialFactory(config, &azurePublicEnvironment)
+	require.Error(t, err, "msi_endpoint must not silently override the process-wide MSI_ENDPOINT via os.Setenv")
+	assert.Equal(t, "https://process-wide-endpoint.example.com/", os.Getenv("MSI_ENDPOINT"), "credentialFactory must not mutate the process environment")
+}
+
+func TestCredentialFactoryMSIEndpointMatchingProcessEnvIsAccepted(t *testing.T) {
+	t.Setenv("MSI_ENDPOINT", "https://process-wide-endpoint.example.com/")
+
+	config := RemoteStateConfigAzureRM{
+		UseMSI:      true,
+		MSIEndpoint: "https://process-wide-endpoint.example.com/",
+	}
+
+	cred, err := credentialFactory(config, &azurePublicEnvironment)
+	require.NoError(t, err)
+	assert.NotNil(t, cred)
+}
+
+func TestResolveAccountSKUName(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, armstorage.SKUName("Standard_LRS"), resolveAccountSKUName(&ExtendedRemoteStateConfigAzureRM{}))
+	assert.Equal(t, armstorage.SKUName("Premium_ZRS"), resolveAccountSKUName(&ExtendedRemoteStateConfigAzureRM{
+		AccountTier:            "Premium",
+		AccountReplicationType: "ZRS",
+	}))
+}
+
+func TestResolveAzureEnvironment(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		environment    string
+		expectedSuffix string
+	}{
+		{"default to public", "", "core.windows.net"},
+		{"public aliases", "AzurePublic", "core.windows.net"},
+		{"us government", "usgovernment", "core.usgovcloudapi.net"},
+		{"china", "china", "core.chinacloudapi.cn"},
+		{"german", "german", "core.cloudapi.de"},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			env, err := resolveAzureEnvironment(testCase.environment)
+			require.NoError(t, err)
+			assert.Equal(t, testCase.expectedSuffix, env.StorageEndpointSuffix)
+		})
+	}
+}
+
+func TestResolveAzureEnvironmentCustomMetadataHost(t *testing.T) {
+	t.Parallel()
+
+	env, err := resolveAzureEnvironment("https://management.stack.example.com/")
+	require.NoError(t, err)
+	assert.Equal(t, "core.windows.net", env.StorageEndpointSuffix)
+	assert.Equal(t, "https://management.stack.example.com/", env.ActiveDirectoryAuthorityHost)
+}
+
+func TestResolveAzureEnvironmentInvalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := resolveAzureEnvironment("not-a-real-cloud")
+	require.Error(t, err)
+}
+
+func TestGetTerraformInitArgsSplitsCustomMetadataHost(t *testing.T) {
+	t.Parallel()
+
+	args := AzureRMInitializer{}.GetTerraformInitArgs(map[string]interface{}{
+		"storage_account_name": "mystorageaccount",
+		"environment":          "https://management.stack.example.com/",
+	})
+
+	assert.Equal(t, "https://management.stack.example.com/", args["metadata_host"])
+	assert.NotContains(t, args, "environment")
+}
+
+func TestGetTerraformInitArgsPassesThroughNamedEnvironment(t *testing.T) {
+	t.Parallel()
+
+	args := AzureRMInitializer{}.GetTerraformInitArgs(map[string]interface{}{
+		"storage_account_name": "mystorageaccount",
+		"environment":          "usgovernment",
+	})
+
+	assert.Equal(t, "usgovernment", args["environment"])
+	assert.NotContains(t, args, "metadata_host")
+}
+
+func TestJoinBlobKeyPrefix(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "terraform.tfstate", joinBlobKeyPrefix("", "terraform.tfstate"))
+	assert.Equal(t, "env/prod/terraform.tfstate", joinBlobKeyPrefix("env/prod", "terraform.tfstate"))
+	assert.Equal(t, "env/prod/terraform.tfstate", joinBlobKeyPrefix("/env/prod/", "/terraform.tfstate"))
+}
+
+func TestValidateAzureBlobPath(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{"simple key", "terraform.tfstate", false},
+		{"prefixed key", "env/prod/terraform.tfstate", false},
+		{"backslash", `env\prod\terraform.tfstate`, true},
+		{"double slash", "env//prod/terraform.tfstate", true},
+		{"dot segment", "env/./terraform.tfstate", true},
+		{"dot-dot segment", "env/../terraform.tfstate", true},
+	}
+
+	for _, testCase := range testCases {
+		testCase := testCase
+		t.Run(testCase.name, func(t *testing.T) {
+			t.Parallel()
+			err := validateAzureBlobPath(testCase.path)
+			if testCase.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestGetTerraformInitArgsFoldsKeyPrefixIntoKey(t *testing.T) {
+	t.Parallel()
+
+	args := AzureRMInitializer{}.GetTerraformInitArgs(map[string]interface{}{
+		"storage_account_name": "mystorageaccount",
+		"key":                  "terraform.tfstate",
+		"key_prefix":           "env/prod",
+	})
+
+	assert.Equal(t, "env/prod/terraform.tfstate", args["key"])
+	assert.NotContains(t, args, "key_prefix")
+}
+
+func TestBoolOrDefault(t *testing.T) {
+	t.Parallel()
+
+	assert.True(t, boolOrDefault(nil, true))
+	assert.False(t, boolOrDefault(nil, false))
+	assert.False(t, boolOrDefault(to.Ptr(false), true))
+	assert.True(t, boolOrDefault(to.Ptr(true), false))
+}